@@ -2,100 +2,247 @@ package main
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
 	"database/sql"
-	"encoding/json"
+	"encoding/base64"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	// PostgreSQL driver
 	"golang.org/x/oauth2"
+
+	// PostgreSQL driver
+	_ "github.com/lib/pq"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/Devon-ODell/paycor-training/connector"
+	"github.com/Devon-ODell/paycor-training/connector/paycor"
+	"github.com/Devon-ODell/paycor-training/health"
+	"github.com/Devon-ODell/paycor-training/logging"
+	"github.com/Devon-ODell/paycor-training/oauthstate"
+	"github.com/Devon-ODell/paycor-training/sync"
+	"github.com/Devon-ODell/paycor-training/tokenstore"
 )
 
 // --- Configuration ---
 // Load these from environment variables for security
 
 var (
-	paycorClientID     string
-	paycorClientSecret string
-	paycorRedirectURL  string
-	postgresDSN        string                         // Data Source Name (e.g., "postgres://user:password@db:5432/paycordb?sslmode=disable")
-	oauthStateString   = "random-string-for-security" // Use a dynamically generated, securely stored state
-	oauth2Config       *oauth2.Config
-	db                 *sql.DB
-	// Store token temporarily in memory for this example.
-	// In production, store securely (e.g., encrypted in DB or secure storage).
-	accessToken *oauth2.Token
+	paycorClientID      string
+	paycorClientSecret  string
+	paycorRedirectURL   string
+	postgresDSN         string // Data Source Name (e.g., "postgres://user:password@db:5432/paycordb?sslmode=disable")
+	tokenStoreMasterKey string // Master secret used to derive the tokenstore's AES-GCM key
+	oauthStateSecret    string // Secret used to sign oauth "state" values
+	adminAPIToken       string // Bearer token required on /admin/ endpoints
+	db                  *sql.DB
+	tokens              *tokenstore.Store
+	states              *oauthstate.Store
+	healthHandler       *health.Handler
+	syncWorker          *sync.Worker
+	logger              *slog.Logger
 )
 
-// --- Paycor API Specifics (PLACEHOLDERS - Get from Paycor Docs) ---
-const (
-	// Replace with actual Paycor Sandbox URLs
-	paycorAuthURL  = "https://login-sandbox.paycor.com/oauth/authorize" // Example - Verify!
-	paycorTokenURL = "https://login-sandbox.paycor.com/oauth/token"     // Example - Verify!
-	// Replace with an actual Paycor Sandbox API endpoint
-	paycorAPIEndpoint = "https://api-sandbox.paycor.com/v1/users/me" // Example - Verify!
-)
+// sessionCookieName is the cookie that carries a browser's opaque session
+// ID; the actual token lives in Postgres, keyed by that ID.
+const sessionCookieName = "paycor_session"
 
-// --- Structs for API Responses (Adapt based on actual Paycor response) ---
-type PaycorUser struct {
-	ID        string `json:"id"` // Example field
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	// Add other relevant fields based on the API endpoint you call
-}
+// connectorCookieName carries the name of the connector a session
+// authenticated against, so /callback and /fetch know which one to use
+// without the IdP having to round-trip our query params for us.
+const connectorCookieName = "paycor_connector"
+
+// defaultConnector is used when a request doesn't specify ?connector=.
+const defaultConnector = "paycor"
+
+// tokenGCGrace is how long past a token's Expiry the tokenstore keeps it
+// around before garbage-collecting it, to give refresh a chance to happen.
+const tokenGCGrace = 24 * time.Hour
+
+// oauthStateTTL is how long an issued oauth state (and its PKCE
+// code_verifier) remains valid before it's rejected as expired.
+const oauthStateTTL = 10 * time.Minute
 
 // --- Initialization ---
 func init() {
+	logger = logging.New()
+
 	// Load configuration from environment variables
 	paycorClientID = os.Getenv("PAYCOR_CLIENT_ID")
 	paycorClientSecret = os.Getenv("PAYCOR_CLIENT_SECRET")
 	paycorRedirectURL = os.Getenv("PAYCOR_REDIRECT_URL") // e.g., "http://localhost/callback" or "http://yourdomain.com/callback"
 	postgresDSN = os.Getenv("POSTGRES_DSN")
+	tokenStoreMasterKey = os.Getenv("TOKEN_STORE_MASTER_KEY")
+	oauthStateSecret = os.Getenv("OAUTH_STATE_SECRET")
+	adminAPIToken = os.Getenv("ADMIN_API_TOKEN")
 
-	if paycorClientID == "" || paycorClientSecret == "" || paycorRedirectURL == "" || postgresDSN == "" {
-		log.Fatal("Error: Required environment variables not set (PAYCOR_CLIENT_ID, PAYCOR_CLIENT_SECRET, PAYCOR_REDIRECT_URL, POSTGRES_DSN)")
+	if paycorClientID == "" || paycorClientSecret == "" || paycorRedirectURL == "" || postgresDSN == "" || tokenStoreMasterKey == "" || oauthStateSecret == "" || adminAPIToken == "" {
+		fatal("required environment variables not set (PAYCOR_CLIENT_ID, PAYCOR_CLIENT_SECRET, PAYCOR_REDIRECT_URL, POSTGRES_DSN, TOKEN_STORE_MASTER_KEY, OAUTH_STATE_SECRET, ADMIN_API_TOKEN)")
 	}
 
-	// Configure OAuth2
-	oauth2Config = &oauth2.Config{
-		ClientID:     paycorClientID,
-		ClientSecret: paycorClientSecret,
-		RedirectURL:  paycorRedirectURL,
-		Scopes:       []string{"openid", "profile", "offline_access", "paycor.payroll.read"}, // Adjust scopes as needed! Consult Paycor docs.
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  paycorAuthURL,
-			TokenURL: paycorTokenURL,
-		},
-	}
+	// Register connectors. Additional HRIS providers (ADP, Workday, Gusto,
+	// BambooHR, ...) register themselves here too; the handlers below don't
+	// need to change to support them.
+	connector.Register(paycor.New(paycorClientID, paycorClientSecret, paycorRedirectURL))
 
 	// Initialize Database Connection
 	var err error
 	db, err = sql.Open("postgres", postgresDSN)
 	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+		fatal("error connecting to database", "error", err)
 	}
 	// Ping DB to ensure connection is valid
 	err = db.Ping()
 	if err != nil {
 		// Retry logic could be added here for Docker Compose startup timing
-		log.Printf("Error pinging database, retrying in 5 seconds: %v", err)
+		logger.Warn("error pinging database, retrying in 5 seconds", "error", err)
 		time.Sleep(5 * time.Second)
 		err = db.Ping()
 		if err != nil {
-			log.Fatalf("Error pinging database after retry: %v", err)
+			fatal("error pinging database after retry", "error", err)
+		}
+	}
+	logger.Info("database connection successful")
+
+	tokens, err = tokenstore.New(db, tokenStoreMasterKey, tokenGCGrace, logger)
+	if err != nil {
+		fatal("error initializing token store", "error", err)
+	}
+
+	states, err = oauthstate.New(db, oauthStateSecret, oauthStateTTL)
+	if err != nil {
+		fatal("error initializing oauth state store", "error", err)
+	}
+
+	defaultConn, ok := connector.Get(defaultConnector)
+	if !ok {
+		fatal("error initializing health checks: connector not registered", "connector", defaultConnector)
+	}
+	healthHandler, err = health.New(db, defaultConn.Config().Endpoint.TokenURL)
+	if err != nil {
+		fatal("error initializing health checks", "error", err)
+	}
+
+	employees, err := sync.Employees(paycor.APIBase, os.Getenv("SYNC_EMPLOYEES_SCHEDULE"))
+	if err != nil {
+		fatal("error parsing SYNC_EMPLOYEES_SCHEDULE", "error", err)
+	}
+	payStatements, err := sync.PayStatements(paycor.APIBase, os.Getenv("SYNC_PAY_STATEMENTS_SCHEDULE"))
+	if err != nil {
+		fatal("error parsing SYNC_PAY_STATEMENTS_SCHEDULE", "error", err)
+	}
+	timeOff, err := sync.TimeOff(paycor.APIBase, os.Getenv("SYNC_TIME_OFF_SCHEDULE"))
+	if err != nil {
+		fatal("error parsing SYNC_TIME_OFF_SCHEDULE", "error", err)
+	}
+
+	syncWorker, err = sync.New(db, tenantLister{}, logger, employees, payStatements, timeOff)
+	if err != nil {
+		fatal("error initializing sync worker", "error", err)
+	}
+}
+
+// tenantLister adapts the token store and default connector to
+// sync.TenantLister. A tenant is a stable identity (see tenantID), recorded
+// against a session once handleFetchData resolves it, not the session
+// itself; a session is just however a tenant happens to currently be
+// authenticated.
+type tenantLister struct{}
+
+func (tenantLister) Tenants(ctx context.Context) ([]string, error) {
+	return tokens.Tenants(ctx)
+}
+
+func (tenantLister) ClientFor(ctx context.Context, tenant string) (*http.Client, error) {
+	sessionID, err := tokens.SessionForTenant(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("finding session for tenant %s: %w", tenant, err)
+	}
+	token, nonce, err := tokens.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("loading token for tenant %s: %w", tenant, err)
+	}
+	conn, ok := connector.Get(defaultConnector)
+	if !ok {
+		return nil, fmt.Errorf("connector %q not registered", defaultConnector)
+	}
+	src := tokens.NewPersistingTokenSource(ctx, sessionID, conn.Config().TokenSource(ctx, token), token, nonce)
+	return oauth2.NewClient(ctx, src), nil
+}
+
+// tenantID derives the stable tenant identity for a fetched profile,
+// namespaced by connector so two providers can't collide on the same
+// external ID.
+func tenantID(conn connector.Connector, user connector.CanonicalUser) string {
+	return conn.Name() + ":" + user.ExternalID
+}
+
+// fatal logs msg at error level and exits, since slog has no Fatal level.
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// clientIP returns the request's peer address with any port stripped.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// newSessionID returns a random, URL-safe session identifier.
+func newSessionID() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// sessionIDFromRequest returns the session ID carried by the request's
+// cookie, or "" if none is set.
+func sessionIDFromRequest(r *http.Request) string {
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// connectorFromRequest resolves which connector a request is acting
+// against: the "connector" query param during /login, falling back to the
+// connector the session authenticated with, falling back to defaultConnector.
+func connectorFromRequest(r *http.Request) (connector.Connector, error) {
+	name := r.URL.Query().Get("connector")
+	if name == "" {
+		if c, err := r.Cookie(connectorCookieName); err == nil {
+			name = c.Value
 		}
 	}
-	log.Println("Database connection successful!")
+	if name == "" {
+		name = defaultConnector
+	}
+	conn, ok := connector.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown connector %q (available: %v)", name, connector.Names())
+	}
+	return conn, nil
 }
 
 // --- HTTP Handlers ---
 
 func handleRoot(w http.ResponseWriter, r *http.Request) {
-	if accessToken == nil || !accessToken.Valid() {
+	sessionID := sessionIDFromRequest(r)
+	tok, _, err := tokens.Get(r.Context(), sessionID)
+	if sessionID == "" || err != nil || !tok.Valid() {
 		// If not authenticated, show login link
 		fmt.Fprintln(w, `<html><body>
             <h2>Paycor Integration Example</h2>
@@ -113,125 +260,260 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleLogin(w http.ResponseWriter, r *http.Request) {
-	// Redirect user to Paycor for authorization
-	url := oauth2Config.AuthCodeURL(oauthStateString) // Pass the state
+	conn, err := connectorFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error generating session ID", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    sessionID,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     connectorCookieName,
+		Value:    conn.Name(),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	pending, err := states.Issue(r.Context(), clientIP(r))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error issuing oauth state", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// Redirect user to the connector's IdP for authorization, with a
+	// signed single-use state and a PKCE code_challenge.
+	url := conn.Config().AuthCodeURL(pending.State,
+		oauth2.SetAuthURLParam("code_challenge", pending.CodeChallenge),
+		oauth2.SetAuthURLParam("code_challenge_method", pending.CodeChallengeMethod),
+	)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
 func handleCallback(w http.ResponseWriter, r *http.Request) {
+	reqLogger := logging.FromContext(r.Context())
+
 	// Handle the callback from Paycor after authorization
-	state := r.FormValue("state")
-	if state != oauthStateString {
-		log.Printf("Invalid oauth state, expected '%s', got '%s'\n", oauthStateString, state)
+	codeVerifier, err := states.Consume(r.Context(), r.FormValue("state"), clientIP(r))
+	if err != nil {
+		reqLogger.Warn("invalid oauth state", "error", err)
 		http.Error(w, "Invalid OAuth State", http.StatusBadRequest)
 		return
 	}
 
+	sessionID := sessionIDFromRequest(r)
+	if sessionID == "" {
+		reqLogger.Warn("oauth callback received without a session cookie")
+		http.Error(w, "Missing session", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := connectorFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	code := r.FormValue("code")
 	if code == "" {
-		log.Println("OAuth code not found in callback")
+		reqLogger.Warn("oauth code not found in callback")
 		http.Error(w, "Code not found", http.StatusBadRequest)
 		return
 	}
 
-	// Exchange authorization code for an access token
-	token, err := oauth2Config.Exchange(context.Background(), code)
+	// Exchange authorization code for an access token, presenting the PKCE
+	// code_verifier that matches the code_challenge sent from /login.
+	token, err := conn.Config().Exchange(context.Background(), code,
+		oauth2.SetAuthURLParam("code_verifier", codeVerifier))
 	if err != nil {
-		log.Printf("oauthConfig.Exchange() failed with '%s'\n", err)
+		reqLogger.Error("oauth exchange failed", "error", err)
 		http.Error(w, "Failed to exchange token", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Access Token received (type: %s, expiry: %s)", token.TokenType, token.Expiry)
-	accessToken = token // Store token (insecurely in memory for this example)
+	reqLogger.Info("access token received", "token_type", token.TokenType, "expiry", token.Expiry)
+	if err := tokens.Put(r.Context(), sessionID, token); err != nil {
+		reqLogger.Error("error persisting token", "error", err)
+		http.Error(w, "Failed to store token", http.StatusInternalServerError)
+		return
+	}
 
 	// Redirect to the root page or a success page
 	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 }
 
 func handleFetchData(w http.ResponseWriter, r *http.Request) {
-	if accessToken == nil || !accessToken.Valid() {
-		log.Println("Fetch attempt without valid token, redirecting to login")
+	reqLogger := logging.FromContext(r.Context())
+
+	sessionID := sessionIDFromRequest(r)
+	token, nonce, err := tokens.Get(r.Context(), sessionID)
+	if sessionID == "" || err != nil || !token.Valid() {
+		reqLogger.Info("fetch attempt without valid token, redirecting to login")
 		http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Create an authenticated HTTP client
-	client := oauth2Config.Client(context.Background(), accessToken)
-
-	// Make request to Paycor API
-	resp, err := client.Get(paycorAPIEndpoint)
+	conn, err := connectorFromRequest(r)
 	if err != nil {
-		log.Printf("Error making request to Paycor API: %v", err)
-		http.Error(w, fmt.Sprintf("Error fetching data from Paycor: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Paycor API returned non-OK status: %s", resp.Status)
-		// Read body for more details if possible
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Error fetching data from Paycor: %s - %s", resp.Status, string(bodyBytes)), resp.StatusCode)
+	// Create an authenticated HTTP client whose TokenSource persists any
+	// rotated token back to the store.
+	ctx := r.Context()
+	src := tokens.NewPersistingTokenSource(ctx, sessionID, conn.Config().TokenSource(ctx, token), token, nonce)
+	client := oauth2.NewClient(ctx, src)
+
+	canonicalUser, err := conn.FetchProfile(ctx, client)
+	if err != nil {
+		reqLogger.Error("error fetching profile", "connector", conn.Name(), "error", err)
+		http.Error(w, fmt.Sprintf("Error fetching data from %s: %v", conn.Name(), err), http.StatusInternalServerError)
 		return
 	}
 
-	// Decode the JSON response
-	var paycorData PaycorUser // Use the appropriate struct based on the endpoint
-	if err := json.NewDecoder(resp.Body).Decode(&paycorData); err != nil {
-		log.Printf("Error decoding Paycor API response: %v", err)
-		http.Error(w, fmt.Sprintf("Error decoding Paycor response: %v", err), http.StatusInternalServerError)
+	reqLogger.Info("fetched profile", "connector", canonicalUser.ConnectorName, "external_id", canonicalUser.ExternalID)
+
+	// Now that we know who this session belongs to, record its stable
+	// tenant identity so the sync worker syncs this employee once no
+	// matter how many sessions/logins they accumulate.
+	if err := tokens.SetTenant(ctx, sessionID, tenantID(conn, canonicalUser)); err != nil {
+		reqLogger.Error("error recording tenant for session", "error", err)
+		http.Error(w, "Failed to record tenant", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Successfully fetched data: %+v", paycorData)
-
 	// --- Store data in PostgreSQL ---
-	err = saveUserData(paycorData)
-	if err != nil {
-		log.Printf("Error saving data to database: %v", err)
+	if err := saveUserData(ctx, canonicalUser); err != nil {
 		http.Error(w, fmt.Sprintf("Error saving data to database: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Successfully saved user data for ID: %s to PostgreSQL", paycorData.ID)
+	reqLogger.Info("saved user data", "connector", canonicalUser.ConnectorName, "external_id", canonicalUser.ExternalID)
 
 	// Display fetched data (or confirmation)
 	w.Header().Set("Content-Type", "text/html")
 	fmt.Fprintf(w, `<html><body>
         <h2>Data Fetched and Stored</h2>
-        <p>Successfully fetched data from Paycor and saved to PostgreSQL.</p>
+        <p>Successfully fetched data from %s and saved to PostgreSQL.</p>
         <pre>%+v</pre>
         <a href="/">Back Home</a>
-    </body></html>`, paycorData)
+    </body></html>`, canonicalUser.ConnectorName, canonicalUser)
 }
 
 // --- Database Interaction ---
-func saveUserData(user PaycorUser) error {
-	// Example: Insert or Update (Upsert) user data
-	// Adjust table and column names based on your init.sql
+func saveUserData(ctx context.Context, user connector.CanonicalUser) error {
+	// Upsert by (connector, external_id) so the same canonical row is
+	// reused across refetches regardless of which provider it came from.
+	// raw preserves the provider-native payload for callers that need
+	// fields the canonical shape doesn't carry.
 	sqlStatement := `
-        INSERT INTO users (paycor_id, first_name, last_name, fetched_at)
-        VALUES ($1, $2, $3, NOW())
-        ON CONFLICT (paycor_id) DO UPDATE SET
+        INSERT INTO users (connector_name, external_id, first_name, last_name, email, raw, fetched_at)
+        VALUES ($1, $2, $3, $4, $5, $6, NOW())
+        ON CONFLICT (connector_name, external_id) DO UPDATE SET
             first_name = EXCLUDED.first_name,
             last_name = EXCLUDED.last_name,
+            email = EXCLUDED.email,
+            raw = EXCLUDED.raw,
             fetched_at = NOW();`
 
-	_, err := db.Exec(sqlStatement, user.ID, user.FirstName, user.LastName)
-	return err // Return the error (nil if successful)
+	_, err := db.ExecContext(ctx, sqlStatement, user.ConnectorName, user.ExternalID, user.FirstName, user.LastName, user.Email, user.Raw)
+	if err != nil {
+		logging.FromContext(ctx).Error("error saving user data", "connector", user.ConnectorName, "external_id", user.ExternalID, "error", err)
+	}
+	return err
+}
+
+// authenticateAdmin reports whether r carries the ADMIN_API_TOKEN as a
+// bearer token, comparing in constant time so response latency can't be
+// used to guess the token byte by byte.
+func authenticateAdmin(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	given := auth[len(prefix):]
+	return len(given) == len(adminAPIToken) && hmac.Equal([]byte(given), []byte(adminAPIToken))
+}
+
+// handleAdminForceSync handles POST /admin/sync/{resource}. It validates the
+// resource and kicks off a full resync across every tenant in the
+// background, since fetching every tenant's data can take well beyond a
+// reasonable request timeout; the 202 response reflects that the resync has
+// only been accepted, not completed.
+func handleAdminForceSync(w http.ResponseWriter, r *http.Request) {
+	reqLogger := logging.FromContext(r.Context())
+
+	if !authenticateAdmin(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resource := strings.TrimPrefix(r.URL.Path, "/admin/sync/")
+	if resource == "" {
+		http.Error(w, "Resource required", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the resource name synchronously so a typo gets an immediate
+	// 400 instead of silently failing in the background.
+	if !syncWorker.HasResource(resource) {
+		http.Error(w, fmt.Sprintf("unknown resource %q", resource), http.StatusBadRequest)
+		return
+	}
+
+	// Run the resync detached from the request's context, which is canceled
+	// as soon as this handler returns.
+	go func() {
+		if err := syncWorker.ForceResync(context.Background(), resource); err != nil {
+			reqLogger.Error("admin force resync failed", "resource", resource, "error", err)
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
 }
 
 // --- Main Function ---
 func main() {
 	defer db.Close() // Ensure database connection is closed when app exits
+	defer tokens.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleRoot)
+	mux.HandleFunc("/login", handleLogin)
+	mux.HandleFunc("/callback", handleCallback)
+	mux.HandleFunc("/fetch", handleFetchData) // Endpoint to trigger data fetch
+
+	mux.HandleFunc("/healthz", healthHandler.Liveness)
+	mux.HandleFunc("/readyz", healthHandler.Readiness)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/admin/sync/", handleAdminForceSync)
+
+	handler := logging.Middleware(logger, sessionCookieName)(mux)
 
-	http.HandleFunc("/", handleRoot)
-	http.HandleFunc("/login", handleLogin)
-	http.HandleFunc("/callback", handleCallback)
-	http.HandleFunc("/fetch", handleFetchData) // Endpoint to trigger data fetch
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	go syncWorker.Start(syncCtx)
 
 	port := "8080" // Internal port the Go app listens on
-	log.Printf("Go server listening on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	logger.Info("go server listening", "port", port)
+	fatal("server exited", "error", http.ListenAndServe(":"+port, handler))
 }
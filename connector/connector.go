@@ -0,0 +1,74 @@
+// Package connector defines the interface HRIS providers implement so the
+// HTTP handlers don't need to hardwire Paycor's auth/token URLs, scopes, or
+// profile shape. New providers register themselves in the package registry
+// and become reachable via the existing handlers immediately.
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// CanonicalUser is the common shape stored in Postgres regardless of which
+// provider a profile came from. Raw preserves the provider-native payload
+// for callers that need fields the canonical shape doesn't carry.
+type CanonicalUser struct {
+	ConnectorName string
+	ExternalID    string
+	FirstName     string
+	LastName      string
+	Email         string
+	Raw           []byte
+}
+
+// Connector adapts one HRIS provider's OAuth2 flow and profile API.
+type Connector interface {
+	// Name is the registry key and the value clients pass as ?connector=.
+	Name() string
+	// Config returns this connector's OAuth2 client configuration.
+	Config() *oauth2.Config
+	// Scopes lists the OAuth2 scopes to request during authorization.
+	Scopes() []string
+	// FetchProfile retrieves and normalizes the authenticated user's profile.
+	FetchProfile(ctx context.Context, client *http.Client) (CanonicalUser, error)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Connector{}
+)
+
+// Register adds c to the registry under c.Name(). It panics on duplicate
+// registration; connectors are expected to register once at startup.
+func Register(c Connector) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := c.Name()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("connector: Register called twice for connector %q", name))
+	}
+	registry[name] = c
+}
+
+// Get returns the connector registered under name, or false if none is.
+func Get(name string) (Connector, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := registry[name]
+	return c, ok
+}
+
+// Names returns the registered connector names.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,102 @@
+// Package paycor implements connector.Connector for Paycor's sandbox API.
+package paycor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"golang.org/x/oauth2"
+
+	"github.com/Devon-ODell/paycor-training/connector"
+)
+
+// PLACEHOLDERS - Get from Paycor Docs
+const (
+	authURL  = "https://login-sandbox.paycor.com/oauth/authorize" // Example - Verify!
+	tokenURL = "https://login-sandbox.paycor.com/oauth/token"     // Example - Verify!
+
+	// APIBase is exported so other packages (e.g. the sync worker) can
+	// build requests against additional Paycor resources.
+	APIBase     = "https://api-sandbox.paycor.com" // Example - Verify!
+	apiEndpoint = APIBase + "/v1/users/me"
+)
+
+// user is Paycor's native /users/me response shape.
+type user struct {
+	ID        string `json:"id"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+}
+
+// Connector implements connector.Connector for Paycor.
+type Connector struct {
+	clientID     string
+	clientSecret string
+	redirectURL  string
+}
+
+// New returns a Paycor connector configured with the given OAuth2 client
+// credentials.
+func New(clientID, clientSecret, redirectURL string) *Connector {
+	return &Connector{clientID: clientID, clientSecret: clientSecret, redirectURL: redirectURL}
+}
+
+func (c *Connector) Name() string { return "paycor" }
+
+// Scopes lists the scopes requested during authorization. Adjust as needed!
+// Consult Paycor docs.
+func (c *Connector) Scopes() []string {
+	return []string{"openid", "profile", "offline_access", "paycor.payroll.read"}
+}
+
+func (c *Connector) Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.clientID,
+		ClientSecret: c.clientSecret,
+		RedirectURL:  c.redirectURL,
+		Scopes:       c.Scopes(),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+		},
+	}
+}
+
+func (c *Connector) FetchProfile(ctx context.Context, client *http.Client) (connector.CanonicalUser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiEndpoint, nil)
+	if err != nil {
+		return connector.CanonicalUser{}, fmt.Errorf("paycor: building request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return connector.CanonicalUser{}, fmt.Errorf("paycor: fetching profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return connector.CanonicalUser{}, fmt.Errorf("paycor: reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return connector.CanonicalUser{}, fmt.Errorf("paycor: API returned %s: %s", resp.Status, raw)
+	}
+
+	var u user
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return connector.CanonicalUser{}, fmt.Errorf("paycor: decoding response: %w", err)
+	}
+
+	return connector.CanonicalUser{
+		ConnectorName: c.Name(),
+		ExternalID:    u.ID,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Email:         u.Email,
+		Raw:           raw,
+	}, nil
+}
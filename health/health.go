@@ -0,0 +1,163 @@
+// Package health implements liveness and readiness HTTP probes.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	checkDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "healthcheck_duration_seconds",
+		Help: "Duration of readiness dependency checks, in seconds.",
+	}, []string{"check"})
+	checkFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "healthcheck_failures_total",
+		Help: "Number of readiness dependency checks that failed, by check.",
+	}, []string{"check"})
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS health_checks (
+	id         TEXT PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+// probeTimeout bounds each individual readiness dependency check.
+const probeTimeout = 2 * time.Second
+
+// Handler serves the /healthz and /readyz probes.
+type Handler struct {
+	db          *sql.DB
+	idpProbeURL string
+	httpClient  *http.Client
+}
+
+// New returns a Handler. db is round-tripped for the readiness DB check;
+// idpProbeURL (e.g. the IdP's token endpoint) is HEAD/OPTIONS-probed to
+// verify outbound connectivity.
+func New(db *sql.DB, idpProbeURL string) (*Handler, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("health: creating schema: %w", err)
+	}
+	return &Handler{
+		db:          db,
+		idpProbeURL: idpProbeURL,
+		httpClient:  &http.Client{Timeout: probeTimeout},
+	}, nil
+}
+
+// checkResult is one dependency's readiness result.
+type checkResult struct {
+	Status    string `json:"status"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Liveness handles /healthz: a cheap, in-process check that the process is
+// up and able to respond at all. It exercises no external dependency.
+func (h *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness handles /readyz: it exercises the actual dependencies (an
+// insert+delete round-trip against Postgres, and a probe against the IdP)
+// so a stuck DB, a read-only replica, or a down IdP fails the probe instead
+// of the bare liveness check.
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	results := map[string]checkResult{
+		"db":     h.checkDB(r.Context()),
+		"paycor": h.checkIdP(r.Context()),
+	}
+
+	status := http.StatusOK
+	for _, res := range results {
+		if res.Status != "ok" {
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, status, results)
+}
+
+func (h *Handler) checkDB(ctx context.Context) checkResult {
+	start := time.Now()
+	err := h.roundTripDB(ctx)
+	elapsed := time.Since(start)
+	checkDuration.WithLabelValues("db").Observe(elapsed.Seconds())
+	if err != nil {
+		checkFailures.WithLabelValues("db").Inc()
+	}
+	return toResult(elapsed, err)
+}
+
+func (h *Handler) roundTripDB(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	id := fmt.Sprintf("healthz-%d", time.Now().UnixNano())
+	if _, err := h.db.ExecContext(ctx, `INSERT INTO health_checks (id) VALUES ($1)`, id); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	if _, err := h.db.ExecContext(ctx, `DELETE FROM health_checks WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	return nil
+}
+
+func (h *Handler) checkIdP(ctx context.Context) checkResult {
+	start := time.Now()
+	err := h.probeIdP(ctx)
+	elapsed := time.Since(start)
+	checkDuration.WithLabelValues("paycor").Observe(elapsed.Seconds())
+	if err != nil {
+		checkFailures.WithLabelValues("paycor").Inc()
+	}
+	return toResult(elapsed, err)
+}
+
+// probeIdP tries HEAD first since it's cheapest; some IdPs reject HEAD
+// outright, so OPTIONS is tried before declaring outbound connectivity
+// broken.
+func (h *Handler) probeIdP(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	if err := h.doProbe(ctx, http.MethodHead); err == nil {
+		return nil
+	}
+	return h.doProbe(ctx, http.MethodOptions)
+}
+
+func (h *Handler) doProbe(ctx context.Context, method string) error {
+	req, err := http.NewRequestWithContext(ctx, method, h.idpProbeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+func toResult(elapsed time.Duration, err error) checkResult {
+	if err != nil {
+		return checkResult{Status: "error", ElapsedMS: elapsed.Milliseconds(), Error: err.Error()}
+	}
+	return checkResult{Status: "ok", ElapsedMS: elapsed.Milliseconds()}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,111 @@
+// Package logging configures log/slog for the app and provides HTTP
+// middleware that assigns each request a correlation ID, threading a
+// logger scoped to that ID through context.Context.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// requestIDHeader is the header inbound requests may set to propagate a
+// correlation ID from an upstream caller, and that the response echoes it
+// back on.
+const requestIDHeader = "X-Request-ID"
+
+// New builds a *slog.Logger whose handler and level are selected by the
+// LOG_FORMAT ("text" or "json", default "text") and LOG_LEVEL
+// ("debug"|"info"|"warn"|"error", default "info") env vars.
+func New() *slog.Logger {
+	opts := &slog.HandlerOptions{Level: levelFromEnv()}
+
+	var handler slog.Handler
+	if os.Getenv("LOG_FORMAT") == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+	return slog.New(handler)
+}
+
+func levelFromEnv() slog.Level {
+	switch os.Getenv("LOG_LEVEL") {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type contextKey int
+
+const loggerKey contextKey = 0
+
+// WithLogger returns a context carrying logger, retrievable with FromContext.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger attached by Middleware, or slog.Default()
+// if none is present (e.g. outside a request).
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Middleware assigns each request a correlation ID (honoring an inbound
+// X-Request-ID header), attaches it to logger, echoes it back in the
+// response header, and emits a single access log line per request with
+// method, path, status, duration, and the session ID cookie if present.
+func Middleware(logger *slog.Logger, sessionCookieName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(requestIDHeader)
+			if requestID == "" {
+				requestID = uuid.NewString()
+			}
+			w.Header().Set(requestIDHeader, requestID)
+
+			reqLogger := logger.With("request_id", requestID)
+			ctx := WithLogger(r.Context(), reqLogger)
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			start := time.Now()
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			attrs := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"duration_ms", time.Since(start).Milliseconds(),
+			}
+			if c, err := r.Cookie(sessionCookieName); err == nil {
+				attrs = append(attrs, "session_id", c.Value)
+			}
+			reqLogger.Info("http request", attrs...)
+		})
+	}
+}
+
+// statusRecorder captures the status code written so the access log line
+// can report it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,151 @@
+// Package oauthstate replaces a hardcoded oauth "state" constant with
+// signed, single-use, per-request values bound to the requesting client,
+// and layers PKCE (RFC 7636) on top of them.
+package oauthstate
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalid is returned for a state that is malformed, expired, already
+// consumed, or whose signature doesn't match the requesting client.
+var ErrInvalid = errors.New("oauthstate: state invalid, expired, or already used")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS oauth_states (
+	state           TEXT PRIMARY KEY,
+	code_verifier   TEXT NOT NULL,
+	client_ip       TEXT NOT NULL,
+	created_at_unix BIGINT NOT NULL,
+	expires_at      TIMESTAMPTZ NOT NULL
+);`
+
+// Store issues and validates signed, single-use OAuth2 state values
+// together with their PKCE code_verifier.
+type Store struct {
+	db     *sql.DB
+	secret []byte
+	ttl    time.Duration
+}
+
+// New returns a Store whose HMAC signatures are keyed by secret. ttl bounds
+// how long an issued state remains valid (e.g. 10 minutes).
+func New(db *sql.DB, secret string, ttl time.Duration) (*Store, error) {
+	if secret == "" {
+		return nil, errors.New("oauthstate: secret must not be empty")
+	}
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("oauthstate: creating schema: %w", err)
+	}
+	return &Store{db: db, secret: []byte(secret), ttl: ttl}, nil
+}
+
+// Pending is a freshly issued state/PKCE pair to attach to an authorization
+// request via oauth2.Config.AuthCodeURL.
+type Pending struct {
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+const (
+	nonceSize = 16
+	macSize   = sha256.Size
+)
+
+// Issue generates a new state bound to clientIP, persists it (and a freshly
+// generated PKCE code_verifier) for later single-use validation in Consume.
+func (s *Store) Issue(ctx context.Context, clientIP string) (*Pending, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("oauthstate: generating nonce: %w", err)
+	}
+
+	verifier, err := newCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	mac := s.sign(nonce, clientIP, now.Unix())
+	state := base64.RawURLEncoding.EncodeToString(append(nonce, mac...))
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO oauth_states (state, code_verifier, client_ip, created_at_unix, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		state, verifier, clientIP, now.Unix(), now.Add(s.ttl))
+	if err != nil {
+		return nil, fmt.Errorf("oauthstate: persisting state: %w", err)
+	}
+
+	return &Pending{
+		State:               state,
+		CodeChallenge:       codeChallengeS256(verifier),
+		CodeChallengeMethod: "S256",
+	}, nil
+}
+
+// Consume atomically deletes the unexpired row for state so it cannot be
+// replayed, then checks its signature against clientIP, returning the PKCE
+// code_verifier to pass to the token exchange. A state that fails the
+// signature check has already been deleted and cannot be retried.
+func (s *Store) Consume(ctx context.Context, state, clientIP string) (codeVerifier string, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(state)
+	if err != nil || len(raw) != nonceSize+macSize {
+		return "", ErrInvalid
+	}
+	nonce, mac := raw[:nonceSize], raw[nonceSize:]
+
+	var createdAtUnix int64
+	row := s.db.QueryRowContext(ctx, `
+		DELETE FROM oauth_states
+		WHERE state = $1 AND expires_at > NOW()
+		RETURNING code_verifier, created_at_unix`, state)
+	if err := row.Scan(&codeVerifier, &createdAtUnix); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrInvalid
+		}
+		return "", fmt.Errorf("oauthstate: consuming state: %w", err)
+	}
+
+	expected := s.sign(nonce, clientIP, createdAtUnix)
+	if !hmac.Equal(mac, expected) {
+		return "", ErrInvalid
+	}
+	return codeVerifier, nil
+}
+
+func (s *Store) sign(nonce []byte, clientIP string, unixTime int64) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(nonce)
+	h.Write([]byte(clientIP))
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(unixTime))
+	h.Write(ts[:])
+	return h.Sum(nil)
+}
+
+// newCodeVerifier generates a PKCE code_verifier per RFC 7636 section 4.1.
+func newCodeVerifier() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("oauthstate: generating code_verifier: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// codeChallengeS256 derives a PKCE code_challenge from verifier using the
+// S256 transform: base64url(SHA256(verifier)).
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,118 @@
+package oauthstate
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// testDB opens a connection to a real Postgres instance for tests that rely
+// on Consume's atomic DELETE ... RETURNING to prove single-use enforcement.
+// Set POSTGRES_TEST_DSN to run them; otherwise they're skipped.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set; skipping test that requires Postgres")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("opening test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func randIP(t *testing.T) string {
+	t.Helper()
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		t.Fatalf("generating ip: %v", err)
+	}
+	return "203.0.113." + hex.EncodeToString(buf[:1])
+}
+
+func TestIssueConsume_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(testDB(t), "test-secret", time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	clientIP := randIP(t)
+	pending, err := s.Issue(ctx, clientIP)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	verifier, err := s.Consume(ctx, pending.State, clientIP)
+	if err != nil {
+		t.Fatalf("Consume: %v", err)
+	}
+	if verifier == "" {
+		t.Fatal("Consume returned an empty code_verifier")
+	}
+}
+
+func TestConsume_RejectsReuse(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(testDB(t), "test-secret", time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	clientIP := randIP(t)
+	pending, err := s.Issue(ctx, clientIP)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if _, err := s.Consume(ctx, pending.State, clientIP); err != nil {
+		t.Fatalf("first Consume: %v", err)
+	}
+	if _, err := s.Consume(ctx, pending.State, clientIP); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("second Consume: got %v, want ErrInvalid", err)
+	}
+}
+
+func TestConsume_RejectsWrongClientIP(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(testDB(t), "test-secret", time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	pending, err := s.Issue(ctx, randIP(t))
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if _, err := s.Consume(ctx, pending.State, randIP(t)); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Consume from a different IP: got %v, want ErrInvalid", err)
+	}
+}
+
+func TestConsume_RejectsExpiredState(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(testDB(t), "test-secret", -time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	clientIP := randIP(t)
+	pending, err := s.Issue(ctx, clientIP)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if _, err := s.Consume(ctx, pending.State, clientIP); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Consume of an already-expired state: got %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingResource is a minimal Resource whose Fetch is scripted by the
+// test: it returns the next entry in errs (a rate-limit error or nil) until
+// the list is exhausted, then succeeds.
+type countingResource struct {
+	errs    []error
+	calls   int
+	records []Record
+}
+
+func (r *countingResource) Name() string                  { return "counting" }
+func (r *countingResource) Schedule() Schedule            { return MustParseSchedule(DefaultEmployeesSchedule) }
+func (r *countingResource) EnsureSchema(db *sql.DB) error { return nil }
+func (r *countingResource) Store(ctx context.Context, db *sql.DB, tenant string, records []Record) error {
+	return nil
+}
+
+func (r *countingResource) Fetch(ctx context.Context, client *http.Client, cursor string) ([]Record, string, error) {
+	if r.calls < len(r.errs) {
+		err := r.errs[r.calls]
+		r.calls++
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		r.calls++
+	}
+	return r.records, "next-cursor", nil
+}
+
+func testWorker() *Worker {
+	return &Worker{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func TestFetchWithBackoff_SucceedsAfterRetryAfterHonored(t *testing.T) {
+	r := &countingResource{
+		errs: []error{
+			&rateLimitError{status: http.StatusTooManyRequests, retryAfter: time.Millisecond},
+			&rateLimitError{status: http.StatusTooManyRequests, retryAfter: time.Millisecond},
+			nil,
+		},
+		records: []Record{{ExternalID: "1"}},
+	}
+	w := testWorker()
+
+	start := time.Now()
+	records, cursor, err := w.fetchWithBackoff(context.Background(), r, &http.Client{}, "")
+	if err != nil {
+		t.Fatalf("fetchWithBackoff: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Millisecond {
+		t.Fatalf("fetchWithBackoff returned after %v, expected to honor Retry-After waits", elapsed)
+	}
+	if r.calls != 3 {
+		t.Fatalf("Fetch called %d times, want 3", r.calls)
+	}
+	if cursor != "next-cursor" {
+		t.Fatalf("cursor = %q, want %q", cursor, "next-cursor")
+	}
+	if len(records) != 1 || records[0].ExternalID != "1" {
+		t.Fatalf("records = %+v, want one record with ExternalID 1", records)
+	}
+}
+
+func TestFetchWithBackoff_NonRetryableErrorReturnsImmediately(t *testing.T) {
+	wantErr := errors.New("boom")
+	r := &countingResource{errs: []error{wantErr}}
+	w := testWorker()
+
+	_, _, err := w.fetchWithBackoff(context.Background(), r, &http.Client{}, "")
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("fetchWithBackoff: got %v, want %v", err, wantErr)
+	}
+	if r.calls != 1 {
+		t.Fatalf("Fetch called %d times, want 1 (no retry for non-rate-limit errors)", r.calls)
+	}
+}
+
+func TestFetchWithBackoff_GivesUpAfterMaxAttempts(t *testing.T) {
+	errs := make([]error, maxBackoffAttempts)
+	for i := range errs {
+		errs[i] = &rateLimitError{status: http.StatusTooManyRequests, retryAfter: time.Millisecond}
+	}
+	r := &countingResource{errs: errs}
+	w := testWorker()
+
+	_, _, err := w.fetchWithBackoff(context.Background(), r, &http.Client{}, "")
+	if err == nil || !strings.Contains(err.Error(), "giving up after") {
+		t.Fatalf("fetchWithBackoff: got %v, want a giving-up error", err)
+	}
+	if r.calls != maxBackoffAttempts {
+		t.Fatalf("Fetch called %d times, want %d", r.calls, maxBackoffAttempts)
+	}
+}
@@ -0,0 +1,177 @@
+package sync
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed standard 5-field cron expression (minute hour
+// day-of-month month day-of-week), used to decide when a Resource's
+// runSchedule loop should next wake up. Each field is a bitmask of the
+// values it allows, built from "*", single values, ranges ("1-5"), lists
+// ("1,15,30"), and step values ("*/15"), combined freely as in "0,30 9-17
+// * * 1-5".
+type Schedule struct {
+	raw                          string
+	minute, hour, dom, month, dow cronField
+}
+
+// cronField is a bitmask over a field's valid range; bit N set means N is
+// allowed. Every field here fits well within 64 bits (the widest, minute,
+// only needs 60).
+type cronField struct {
+	bits uint64
+}
+
+func (f cronField) has(v int) bool { return f.bits&(1<<uint(v)) != 0 }
+
+// isWildcard reports whether the field was "*" over [min, max], i.e. every
+// value in range is allowed. Standard cron semantics check this to decide
+// whether day-of-month and day-of-week restrict a match on their own or
+// only in combination (OR) with each other.
+func (f cronField) isWildcard(min, max int) bool {
+	return f.bits == fullMask(min, max)
+}
+
+func fullMask(min, max int) uint64 {
+	var m uint64
+	for v := min; v <= max; v++ {
+		m |= 1 << uint(v)
+	}
+	return m
+}
+
+// ParseSchedule parses a standard 5-field cron expression: minute (0-59),
+// hour (0-23), day-of-month (1-31), month (1-12), and day-of-week (0-6,
+// Sunday = 0).
+func ParseSchedule(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("sync: cron expression %q must have 5 fields (minute hour dom month dow), got %d", expr, len(fields))
+	}
+
+	minute, err := parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("sync: cron expression %q: minute field: %w", expr, err)
+	}
+	hour, err := parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("sync: cron expression %q: hour field: %w", expr, err)
+	}
+	dom, err := parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("sync: cron expression %q: day-of-month field: %w", expr, err)
+	}
+	month, err := parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("sync: cron expression %q: month field: %w", expr, err)
+	}
+	dow, err := parseCronField(fields[4], 0, 6)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("sync: cron expression %q: day-of-week field: %w", expr, err)
+	}
+
+	return Schedule{raw: expr, minute: minute, hour: hour, dom: dom, month: month, dow: dow}, nil
+}
+
+// MustParseSchedule is ParseSchedule for expressions fixed at compile time,
+// e.g. the package's Default*Schedule constants. It panics on a malformed
+// expression.
+func MustParseSchedule(expr string) Schedule {
+	s, err := ParseSchedule(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the original cron expression.
+func (s Schedule) String() string { return s.raw }
+
+func parseCronField(field string, min, max int) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(field, ",") {
+		span := part
+		step := 1
+		if slash := strings.IndexByte(part, '/'); slash >= 0 {
+			span = part[:slash]
+			n, err := strconv.Atoi(part[slash+1:])
+			if err != nil || n <= 0 {
+				return cronField{}, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case span == "*":
+			lo, hi = min, max
+		case strings.Contains(span, "-"):
+			bounds := strings.SplitN(span, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return cronField{}, fmt.Errorf("invalid range in %q", part)
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return cronField{}, fmt.Errorf("invalid range in %q", part)
+			}
+		default:
+			v, err := strconv.Atoi(span)
+			if err != nil {
+				return cronField{}, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = v, v
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return cronField{}, fmt.Errorf("value out of range [%d,%d] in %q", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			f.bits |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+// maxScheduleLookahead bounds how far into the future Next will search
+// before giving up, so a schedule that (by construction, via ParseSchedule)
+// can never be satisfied doesn't loop forever.
+const maxScheduleLookahead = 5 * 366 * 24 * time.Hour
+
+// Next returns the earliest time strictly after from that matches the
+// schedule, with seconds and smaller truncated away since cron doesn't
+// resolve finer than a minute.
+func (s Schedule) Next(from time.Time) time.Time {
+	t := from.Truncate(time.Minute).Add(time.Minute)
+	for deadline := from.Add(maxScheduleLookahead); t.Before(deadline); t = t.Add(time.Minute) {
+		if s.matches(t) {
+			return t
+		}
+	}
+	return t
+}
+
+// matches follows standard cron day semantics: when both day-of-month and
+// day-of-week are restricted (neither is "*"), a date matches if it
+// satisfies either one; otherwise whichever one is restricted (if any)
+// must match on its own.
+func (s Schedule) matches(t time.Time) bool {
+	if !s.minute.has(t.Minute()) || !s.hour.has(t.Hour()) || !s.month.has(int(t.Month())) {
+		return false
+	}
+
+	domRestricted := !s.dom.isWildcard(1, 31)
+	dowRestricted := !s.dow.isWildcard(0, 6)
+	switch {
+	case domRestricted && dowRestricted:
+		return s.dom.has(t.Day()) || s.dow.has(int(t.Weekday()))
+	case domRestricted:
+		return s.dom.has(t.Day())
+	case dowRestricted:
+		return s.dow.has(int(t.Weekday()))
+	default:
+		return true
+	}
+}
@@ -0,0 +1,362 @@
+// Package sync runs a background worker that periodically pulls Paycor
+// resources (employees, pay statements, time off) into normalized Postgres
+// tables, incrementally and per tenant, instead of only fetching a profile
+// on demand the way handleFetchData does.
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS sync_state (
+	tenant     TEXT NOT NULL,
+	resource   TEXT NOT NULL,
+	cursor     TEXT NOT NULL DEFAULT '',
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (tenant, resource)
+);
+CREATE TABLE IF NOT EXISTS sync_locks (
+	tenant   TEXT NOT NULL,
+	resource TEXT NOT NULL,
+	PRIMARY KEY (tenant, resource)
+);`
+
+var (
+	recordsFetched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sync_records_fetched_total",
+		Help: "Number of resource records pulled from the HRIS API.",
+	}, []string{"resource"})
+	apiLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "sync_api_request_duration_seconds",
+		Help: "Latency of HRIS API requests made by the sync worker.",
+	}, []string{"resource"})
+	syncErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sync_errors_total",
+		Help: "Number of sync runs that ended in an error.",
+	}, []string{"resource"})
+)
+
+// TenantLister returns the tenants (stable identities, not login sessions)
+// the worker should sync on behalf of, and a *http.Client authenticated for
+// each one.
+type TenantLister interface {
+	Tenants(ctx context.Context) ([]string, error)
+	ClientFor(ctx context.Context, tenant string) (*http.Client, error)
+}
+
+// Resource is one HRIS resource the worker keeps in sync, e.g. employees,
+// pay statements, or time off requests.
+type Resource interface {
+	// Name identifies the resource in sync_state, metrics, and the
+	// /admin/sync/{resource} endpoint.
+	Name() string
+	// Schedule is the cron schedule the resource is synced on.
+	Schedule() Schedule
+	// EnsureSchema creates the resource's backing table if it doesn't
+	// already exist.
+	EnsureSchema(db *sql.DB) error
+	// Fetch pulls every record updated since cursor (empty for a full
+	// sync), paging through the API itself, and returns the next cursor to
+	// resume from.
+	Fetch(ctx context.Context, client *http.Client, cursor string) (records []Record, nextCursor string, err error)
+	// Store upserts records for tenant into the resource's table.
+	Store(ctx context.Context, db *sql.DB, tenant string, records []Record) error
+}
+
+// Record is one normalized item returned by Resource.Fetch.
+type Record struct {
+	ExternalID string
+	Raw        []byte
+}
+
+// Worker periodically syncs every registered Resource for every tenant
+// TenantLister reports.
+type Worker struct {
+	db        *sql.DB
+	tenants   TenantLister
+	resources map[string]Resource
+	logger    *slog.Logger
+}
+
+// New returns a Worker. It ensures sync_state and sync_locks exist and calls
+// EnsureSchema on each resource. Per-tenant/resource locking uses a row in
+// sync_locks, taken with SELECT ... FOR UPDATE SKIP LOCKED.
+func New(db *sql.DB, tenants TenantLister, logger *slog.Logger, resources ...Resource) (*Worker, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("sync: creating schema: %w", err)
+	}
+
+	byName := make(map[string]Resource, len(resources))
+	for _, r := range resources {
+		if err := r.EnsureSchema(db); err != nil {
+			return nil, fmt.Errorf("sync: preparing resource %q: %w", r.Name(), err)
+		}
+		byName[r.Name()] = r
+	}
+
+	return &Worker{db: db, tenants: tenants, resources: byName, logger: logger}, nil
+}
+
+// Start launches one ticking goroutine per resource and blocks until ctx is
+// canceled. Call it with `go worker.Start(ctx)` from main.
+func (w *Worker) Start(ctx context.Context) {
+	for _, r := range w.resources {
+		go w.runSchedule(ctx, r)
+	}
+	<-ctx.Done()
+}
+
+// runSchedule syncs r once immediately, then wakes up at each time its cron
+// schedule matches, recomputing the next fire time after every run rather
+// than ticking at a fixed interval so it tracks wall-clock schedules (e.g.
+// "02:00 daily") correctly across DST transitions.
+func (w *Worker) runSchedule(ctx context.Context, r Resource) {
+	w.syncAllTenants(ctx, r, false)
+	for {
+		next := r.Schedule().Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			w.syncAllTenants(ctx, r, false)
+		}
+	}
+}
+
+func (w *Worker) syncAllTenants(ctx context.Context, r Resource, full bool) {
+	tenants, err := w.tenants.Tenants(ctx)
+	if err != nil {
+		w.logger.Error("sync: listing tenants failed", "resource", r.Name(), "error", err)
+		return
+	}
+	for _, tenant := range tenants {
+		if err := w.syncTenant(ctx, r, tenant, full); err != nil {
+			syncErrors.WithLabelValues(r.Name()).Inc()
+			w.logger.Error("sync run failed", "resource", r.Name(), "tenant", tenant, "error", err)
+		}
+	}
+}
+
+// HasResource reports whether resourceName names one of the resources the
+// worker was constructed with.
+func (w *Worker) HasResource(resourceName string) bool {
+	_, ok := w.resources[resourceName]
+	return ok
+}
+
+// ForceResync resets resource's cursor for every known tenant and runs a
+// full sync immediately. It backs the POST /admin/sync/{resource} endpoint.
+func (w *Worker) ForceResync(ctx context.Context, resourceName string) error {
+	r, ok := w.resources[resourceName]
+	if !ok {
+		return fmt.Errorf("sync: unknown resource %q", resourceName)
+	}
+	w.syncAllTenants(ctx, r, true)
+	return nil
+}
+
+// queryExecer is the subset of *sql.DB and *sql.Tx that loadCursor and
+// saveCursor need, so they can run against either a plain connection or the
+// transaction syncTenant holds its row lock in.
+type queryExecer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// syncTenant syncs one resource for one tenant, holding a row lock in
+// sync_locks for the duration so multiple replicas running this worker don't
+// double-sync the same tenant/resource pair. The lock is taken with SELECT
+// ... FOR UPDATE SKIP LOCKED inside a transaction that stays open across the
+// network fetch (which can run for tens of seconds with paging and
+// rate-limit backoff) and only commits once the cursor has advanced, so a
+// crash mid-sync releases the lock instead of leaving it held.
+func (w *Worker) syncTenant(ctx context.Context, r Resource, tenant string, full bool) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning sync transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO sync_locks (tenant, resource) VALUES ($1, $2)
+		ON CONFLICT (tenant, resource) DO NOTHING`, tenant, r.Name()); err != nil {
+		return fmt.Errorf("ensuring lock row: %w", err)
+	}
+
+	var held bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT TRUE FROM sync_locks WHERE tenant = $1 AND resource = $2 FOR UPDATE SKIP LOCKED`, tenant, r.Name()).Scan(&held)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Another replica already holds the lock; skip this cycle rather
+		// than blocking on it.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("acquiring sync lock: %w", err)
+	}
+
+	cursor := ""
+	if !full {
+		cursor, err = w.loadCursor(ctx, tx, tenant, r.Name())
+		if err != nil {
+			return err
+		}
+	}
+
+	client, err := w.tenants.ClientFor(ctx, tenant)
+	if err != nil {
+		return fmt.Errorf("getting client for tenant %s: %w", tenant, err)
+	}
+
+	nextCursor, err := w.fetchAndStore(ctx, r, tenant, client, cursor)
+	if err != nil {
+		return err
+	}
+
+	if err := w.saveCursor(ctx, tx, tenant, r.Name(), nextCursor); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing sync: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+func (w *Worker) loadCursor(ctx context.Context, q queryExecer, tenant, resource string) (string, error) {
+	var cursor string
+	err := q.QueryRowContext(ctx, `
+		SELECT cursor FROM sync_state WHERE tenant = $1 AND resource = $2`, tenant, resource).Scan(&cursor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("loading cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+// saveCursor persists the resource's next cursor for tenant, run only after
+// the (potentially slow) fetch has already completed.
+func (w *Worker) saveCursor(ctx context.Context, q queryExecer, tenant, resource, cursor string) error {
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO sync_state (tenant, resource, cursor, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (tenant, resource) DO UPDATE SET
+			cursor = EXCLUDED.cursor,
+			updated_at = NOW()`, tenant, resource, cursor)
+	if err != nil {
+		return fmt.Errorf("saving cursor: %w", err)
+	}
+	return nil
+}
+
+// fetchAndStore calls r.Fetch (which pages through the HRIS API on its own)
+// with exponential backoff and jitter on 429/5xx, honoring Retry-After when
+// present, then stores whatever records came back.
+func (w *Worker) fetchAndStore(ctx context.Context, r Resource, tenant string, client *http.Client, cursor string) (string, error) {
+	start := time.Now()
+	records, nextCursor, err := w.fetchWithBackoff(ctx, r, client, cursor)
+	apiLatency.WithLabelValues(r.Name()).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return "", fmt.Errorf("fetching %s: %w", r.Name(), err)
+	}
+
+	if len(records) > 0 {
+		if err := r.Store(ctx, w.db, tenant, records); err != nil {
+			return "", fmt.Errorf("storing %s: %w", r.Name(), err)
+		}
+		recordsFetched.WithLabelValues(r.Name()).Add(float64(len(records)))
+	}
+
+	if nextCursor == "" {
+		return cursor, nil
+	}
+	return nextCursor, nil
+}
+
+const maxBackoffAttempts = 5
+
+func (w *Worker) fetchWithBackoff(ctx context.Context, r Resource, client *http.Client, cursor string) ([]Record, string, error) {
+	var lastErr error
+	for attempt := 0; attempt < maxBackoffAttempts; attempt++ {
+		records, nextCursor, err := r.Fetch(ctx, client, cursor)
+		if err == nil {
+			return records, nextCursor, nil
+		}
+
+		var rl *rateLimitError
+		if !errors.As(err, &rl) {
+			return nil, "", err
+		}
+		lastErr = err
+
+		wait := rl.retryAfter
+		if wait <= 0 {
+			wait = backoffWithJitter(attempt)
+		}
+		w.logger.Warn("sync: rate limited, backing off", "resource", r.Name(), "attempt", attempt, "wait", wait)
+
+		select {
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil, "", fmt.Errorf("giving up after %d attempts: %w", maxBackoffAttempts, lastErr)
+}
+
+func backoffWithJitter(attempt int) time.Duration {
+	base := time.Second * time.Duration(1<<attempt)
+	jitter := time.Duration(rand.Int63n(int64(base) / 2))
+	return base + jitter
+}
+
+// rateLimitError signals a 429 or 5xx response, optionally carrying the
+// Retry-After duration the server asked for.
+type rateLimitError struct {
+	status     int
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limited or server error: status %d", e.status)
+}
+
+// NewRateLimitError builds the error Resource.Fetch implementations should
+// return for a 429/5xx response, so fetchWithBackoff knows to retry.
+func NewRateLimitError(resp *http.Response) error {
+	e := &rateLimitError{status: resp.StatusCode}
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.Atoi(ra); err == nil {
+			e.retryAfter = time.Duration(secs) * time.Second
+		}
+	}
+	return e
+}
+
+// IsRetryable reports whether an HRIS API response should be retried with
+// backoff rather than treated as a hard failure.
+func IsRetryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
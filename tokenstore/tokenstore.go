@@ -0,0 +1,378 @@
+// Package tokenstore persists OAuth2 tokens in PostgreSQL instead of the
+// package-level variable the app used to rely on, so sessions survive a
+// restart and more than one user can be signed in at a time.
+package tokenstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNotFound is returned when no token is stored for the given session.
+var ErrNotFound = errors.New("tokenstore: no token for session")
+
+// ErrReplay is returned when a rotated token is persisted against a nonce
+// the store has already moved past, meaning the refresh_token that produced
+// it had already been superseded by the time it was used.
+var ErrReplay = errors.New("tokenstore: stale nonce, refresh_token replay suspected")
+
+// ErrRevoked is returned when a rotated token is persisted for a session
+// whose row has since disappeared (i.e. Revoke ran concurrently), so the
+// rotation isn't applied as a fresh login.
+var ErrRevoked = errors.New("tokenstore: session was revoked before rotation could be persisted")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS oauth_tokens (
+	session_id    TEXT PRIMARY KEY,
+	access_token  BYTEA NOT NULL,
+	refresh_token BYTEA,
+	token_type    TEXT NOT NULL,
+	expiry        TIMESTAMPTZ NOT NULL,
+	nonce         BIGINT NOT NULL DEFAULT 1,
+	tenant_id     TEXT,
+	updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS oauth_tokens_tenant_id_idx ON oauth_tokens (tenant_id) WHERE tenant_id IS NOT NULL;`
+
+// Store persists OAuth2 tokens, encrypting the access and refresh tokens at
+// rest with AES-256-GCM. Each row carries a monotonically increasing nonce
+// that is bumped on every rotation, so a refresh_token that has already been
+// superseded can be told apart from the current one.
+type Store struct {
+	db     *sql.DB
+	gcm    cipher.AEAD
+	logger *slog.Logger
+
+	gcGraceWindow time.Duration
+	stop          chan struct{}
+}
+
+// New derives an AES-256-GCM key from masterSecret (e.g. the raw value of a
+// TOKEN_STORE_MASTER_KEY env var), ensures the backing table exists, and
+// starts a background goroutine that garbage-collects tokens whose Expiry
+// plus gcGraceWindow has passed. Callers should arrange for Close to be
+// called on shutdown.
+func New(db *sql.DB, masterSecret string, gcGraceWindow time.Duration, logger *slog.Logger) (*Store, error) {
+	if masterSecret == "" {
+		return nil, errors.New("tokenstore: master secret must not be empty")
+	}
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("tokenstore: creating schema: %w", err)
+	}
+
+	key := sha256.Sum256([]byte(masterSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("tokenstore: building cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("tokenstore: building GCM: %w", err)
+	}
+
+	s := &Store{db: db, gcm: gcm, logger: logger, gcGraceWindow: gcGraceWindow, stop: make(chan struct{})}
+	go s.gcLoop()
+	return s, nil
+}
+
+// Close stops the background garbage collector. It does not close the
+// underlying *sql.DB, which the caller owns.
+func (s *Store) Close() {
+	close(s.stop)
+}
+
+// SetTenant records the stable tenant identity (e.g.
+// "<connector>:<external_id>") a session belongs to, once it's known from a
+// fetched profile. Sessions with no recorded tenant don't show up in
+// Tenants, so a login that's never completed a fetch isn't synced under a
+// throwaway identity.
+func (s *Store) SetTenant(ctx context.Context, sessionID, tenant string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE oauth_tokens SET tenant_id = $2 WHERE session_id = $1`, sessionID, tenant)
+	if err != nil {
+		return fmt.Errorf("tokenstore: setting tenant: %w", err)
+	}
+	return nil
+}
+
+// Tenants returns the distinct tenant identities that currently have a
+// token on file. Callers use this to discover which tenants have something
+// to sync; unlike session IDs, a tenant is stable across re-logins, so the
+// same employee roster isn't synced once per browser session.
+func (s *Store) Tenants(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT tenant_id FROM oauth_tokens WHERE tenant_id IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("tokenstore: listing tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var tenant string
+		if err := rows.Scan(&tenant); err != nil {
+			return nil, fmt.Errorf("tokenstore: scanning tenant: %w", err)
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// SessionForTenant returns the most recently updated session holding a
+// token for tenant, i.e. the one most likely to still be valid if the
+// employee has logged in more than once. It returns ErrNotFound if no
+// session is on file for tenant.
+func (s *Store) SessionForTenant(ctx context.Context, tenant string) (string, error) {
+	var sessionID string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT session_id FROM oauth_tokens WHERE tenant_id = $1 ORDER BY updated_at DESC LIMIT 1`, tenant).Scan(&sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("tokenstore: finding session for tenant: %w", err)
+	}
+	return sessionID, nil
+}
+
+// Get returns the token stored for sessionID along with its current
+// rotation nonce, or ErrNotFound. Callers that go on to wrap the token in a
+// persisting TokenSource need the nonce to detect a replayed refresh_token;
+// callers that only care whether a token exists can discard it.
+func (s *Store) Get(ctx context.Context, sessionID string) (*oauth2.Token, int64, error) {
+	var accessEnc, refreshEnc []byte
+	var tokenType string
+	var expiry time.Time
+	var nonce int64
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT access_token, refresh_token, token_type, expiry, nonce
+		FROM oauth_tokens WHERE session_id = $1`, sessionID)
+	if err := row.Scan(&accessEnc, &refreshEnc, &tokenType, &expiry, &nonce); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, ErrNotFound
+		}
+		return nil, 0, fmt.Errorf("tokenstore: querying token: %w", err)
+	}
+
+	access, err := s.decrypt(accessEnc)
+	if err != nil {
+		return nil, 0, err
+	}
+	refresh, err := s.decrypt(refreshEnc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &oauth2.Token{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    tokenType,
+		Expiry:       expiry,
+	}, nonce, nil
+}
+
+// Put upserts the token for sessionID, bumping its rotation nonce. An empty
+// RefreshToken leaves the previously stored refresh token in place, since
+// providers don't always re-issue one on every rotation.
+func (s *Store) Put(ctx context.Context, sessionID string, tok *oauth2.Token) error {
+	accessEnc, err := s.encrypt(tok.AccessToken)
+	if err != nil {
+		return err
+	}
+	refreshEnc, err := s.encrypt(tok.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO oauth_tokens (session_id, access_token, refresh_token, token_type, expiry, nonce, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 1, NOW())
+		ON CONFLICT (session_id) DO UPDATE SET
+			access_token = EXCLUDED.access_token,
+			refresh_token = COALESCE(EXCLUDED.refresh_token, oauth_tokens.refresh_token),
+			token_type = EXCLUDED.token_type,
+			expiry = EXCLUDED.expiry,
+			nonce = oauth_tokens.nonce + 1,
+			updated_at = NOW()`,
+		sessionID, accessEnc, refreshEnc, tok.TokenType, tok.Expiry)
+	if err != nil {
+		return fmt.Errorf("tokenstore: storing token: %w", err)
+	}
+	return nil
+}
+
+// putIfCurrent rotates the token for sessionID the way Put does, but first
+// locks the row and compares its stored nonce against expectedNonce: if the
+// store has already moved past expectedNonce, some other rotation beat this
+// one to it, and the caller is handed a replayed refresh_token rather than
+// the one it should have used. In that case the row is left untouched and
+// ErrReplay is returned instead of overwriting a newer token. expectedNonce
+// only ever reaches here from a token this same Store previously returned
+// from Get, so a row that has gone missing means Revoke ran concurrently,
+// not that this is a fresh login; that case returns ErrRevoked instead of
+// resurrecting the session. It returns the nonce the row was rotated to on
+// success.
+func (s *Store) putIfCurrent(ctx context.Context, sessionID string, tok *oauth2.Token, expectedNonce int64) (int64, error) {
+	accessEnc, err := s.encrypt(tok.AccessToken)
+	if err != nil {
+		return 0, err
+	}
+	refreshEnc, err := s.encrypt(tok.RefreshToken)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("tokenstore: beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var storedNonce int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT nonce FROM oauth_tokens WHERE session_id = $1 FOR UPDATE`, sessionID).Scan(&storedNonce)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: session %s", ErrRevoked, sessionID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("tokenstore: locking token row: %w", err)
+	}
+	if storedNonce > expectedNonce {
+		return 0, fmt.Errorf("%w: session %s has nonce %d, caller expected %d", ErrReplay, sessionID, storedNonce, expectedNonce)
+	}
+
+	var newNonce int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO oauth_tokens (session_id, access_token, refresh_token, token_type, expiry, nonce, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 1, NOW())
+		ON CONFLICT (session_id) DO UPDATE SET
+			access_token = EXCLUDED.access_token,
+			refresh_token = COALESCE(EXCLUDED.refresh_token, oauth_tokens.refresh_token),
+			token_type = EXCLUDED.token_type,
+			expiry = EXCLUDED.expiry,
+			nonce = oauth_tokens.nonce + 1,
+			updated_at = NOW()
+		RETURNING nonce`,
+		sessionID, accessEnc, refreshEnc, tok.TokenType, tok.Expiry).Scan(&newNonce)
+	if err != nil {
+		return 0, fmt.Errorf("tokenstore: storing rotated token: %w", err)
+	}
+	return newNonce, tx.Commit()
+}
+
+// Revoke deletes the stored token for sessionID, invalidating any
+// outstanding refresh token along with it.
+func (s *Store) Revoke(ctx context.Context, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM oauth_tokens WHERE session_id = $1`, sessionID)
+	if err != nil {
+		return fmt.Errorf("tokenstore: revoking token: %w", err)
+	}
+	return nil
+}
+
+// NewPersistingTokenSource wraps src so that whenever golang.org/x/oauth2
+// performs a refresh under the hood (i.e. src.Token() returns a token other
+// than current), the rotated token is persisted for sessionID. currentNonce
+// is the rotation nonce current was read with (see Get); the rotation is
+// rejected and audit-logged as a likely replay if the store's nonce has
+// already moved past it, rather than silently overwritten.
+func (s *Store) NewPersistingTokenSource(ctx context.Context, sessionID string, src oauth2.TokenSource, current *oauth2.Token, currentNonce int64) oauth2.TokenSource {
+	return &persistingTokenSource{ctx: ctx, store: s, sessionID: sessionID, src: src, last: current, nonce: currentNonce}
+}
+
+type persistingTokenSource struct {
+	ctx       context.Context
+	store     *Store
+	sessionID string
+	src       oauth2.TokenSource
+	last      *oauth2.Token
+	nonce     int64
+}
+
+func (p *persistingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := p.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	if p.last == nil || tok.AccessToken != p.last.AccessToken {
+		newNonce, err := p.store.putIfCurrent(p.ctx, p.sessionID, tok, p.nonce)
+		if errors.Is(err, ErrReplay) {
+			p.store.logger.Error("tokenstore: rejected rotated token, possible refresh_token replay", "session_id", p.sessionID, "error", err)
+			return nil, err
+		}
+		if errors.Is(err, ErrRevoked) {
+			p.store.logger.Warn("tokenstore: session was revoked, discarding rotated token", "session_id", p.sessionID, "error", err)
+			return nil, err
+		}
+		if err != nil {
+			// Transient failure (e.g. a DB blip): don't advance p.last or
+			// p.nonce, and don't hand the rotated token to the caller,
+			// since we'd otherwise serve it now but never persist it,
+			// leaving the store holding a stale refresh_token the
+			// provider may already consider superseded. Returning the
+			// error instead means the next call retries the rotation.
+			p.store.logger.Error("tokenstore: failed to persist rotated token", "session_id", p.sessionID, "error", err)
+			return nil, err
+		}
+		p.nonce = newNonce
+		p.last = tok
+	}
+	return tok, nil
+}
+
+func (s *Store) encrypt(plaintext string) ([]byte, error) {
+	if plaintext == "" {
+		return nil, nil
+	}
+	nonce := make([]byte, s.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("tokenstore: generating nonce: %w", err)
+	}
+	return s.gcm.Seal(nonce, nonce, []byte(plaintext), nil), nil
+}
+
+func (s *Store) decrypt(ciphertext []byte) (string, error) {
+	if len(ciphertext) == 0 {
+		return "", nil
+	}
+	size := s.gcm.NonceSize()
+	if len(ciphertext) < size {
+		return "", errors.New("tokenstore: ciphertext shorter than nonce")
+	}
+	nonce, data := ciphertext[:size], ciphertext[size:]
+	plaintext, err := s.gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("tokenstore: decrypting: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func (s *Store) gcLoop() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-s.gcGraceWindow)
+			res, err := s.db.Exec(`DELETE FROM oauth_tokens WHERE expiry < $1`, cutoff)
+			if err != nil {
+				s.logger.Error("tokenstore: gc failed", "error", err)
+				continue
+			}
+			if n, err := res.RowsAffected(); err == nil && n > 0 {
+				s.logger.Info("tokenstore: garbage collected expired tokens", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package tokenstore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"golang.org/x/oauth2"
+)
+
+// testDB opens a connection to a real Postgres instance for tests that
+// exercise putIfCurrent's row locking, which a mock can't meaningfully
+// stand in for. Set POSTGRES_TEST_DSN to run them; otherwise they're
+// skipped.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set; skipping test that requires Postgres")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("opening test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	db := testDB(t)
+	s, err := New(db, "test-master-secret", time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// randSessionID keeps each test's rows from colliding with another test's
+// (or a previous run's) in a shared database.
+func randSessionID(t *testing.T) string {
+	t.Helper()
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		t.Fatalf("generating session id: %v", err)
+	}
+	return "test-" + hex.EncodeToString(buf)
+}
+
+func TestPutIfCurrent_AdvancesNonceOnSuccess(t *testing.T) {
+	ctx := context.Background()
+	s := testStore(t)
+	sessionID := randSessionID(t)
+	t.Cleanup(func() { s.Revoke(ctx, sessionID) })
+
+	initial := &oauth2.Token{AccessToken: "access-1", RefreshToken: "refresh-1", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	if err := s.Put(ctx, sessionID, initial); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, nonce, err := s.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if nonce != 1 {
+		t.Fatalf("nonce after Put = %d, want 1", nonce)
+	}
+
+	rotated := &oauth2.Token{AccessToken: "access-2", RefreshToken: "refresh-2", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	newNonce, err := s.putIfCurrent(ctx, sessionID, rotated, nonce)
+	if err != nil {
+		t.Fatalf("putIfCurrent: %v", err)
+	}
+	if newNonce != nonce+1 {
+		t.Fatalf("newNonce = %d, want %d", newNonce, nonce+1)
+	}
+
+	got, gotNonce, err := s.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get after rotation: %v", err)
+	}
+	if got.AccessToken != "access-2" {
+		t.Fatalf("AccessToken = %q, want %q", got.AccessToken, "access-2")
+	}
+	if gotNonce != newNonce {
+		t.Fatalf("stored nonce = %d, want %d", gotNonce, newNonce)
+	}
+}
+
+func TestPutIfCurrent_RejectsStaleNonceAsReplay(t *testing.T) {
+	ctx := context.Background()
+	s := testStore(t)
+	sessionID := randSessionID(t)
+	t.Cleanup(func() { s.Revoke(ctx, sessionID) })
+
+	initial := &oauth2.Token{AccessToken: "access-1", RefreshToken: "refresh-1", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	if err := s.Put(ctx, sessionID, initial); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, nonce, err := s.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	// Rotate once so the stored nonce moves past what the caller below
+	// still expects.
+	rotated := &oauth2.Token{AccessToken: "access-2", RefreshToken: "refresh-2", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	if _, err := s.putIfCurrent(ctx, sessionID, rotated, nonce); err != nil {
+		t.Fatalf("putIfCurrent: %v", err)
+	}
+
+	// A second rotation presenting the same stale nonce is a replayed
+	// refresh_token and must be rejected, not applied.
+	replayed := &oauth2.Token{AccessToken: "access-3", RefreshToken: "refresh-3", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	if _, err := s.putIfCurrent(ctx, sessionID, replayed, nonce); !errors.Is(err, ErrReplay) {
+		t.Fatalf("putIfCurrent with stale nonce: got %v, want ErrReplay", err)
+	}
+
+	got, _, err := s.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get after rejected replay: %v", err)
+	}
+	if got.AccessToken != "access-2" {
+		t.Fatalf("AccessToken after rejected replay = %q, want unchanged %q", got.AccessToken, "access-2")
+	}
+}
+
+func TestPutIfCurrent_RejectsRevokedSession(t *testing.T) {
+	ctx := context.Background()
+	s := testStore(t)
+	sessionID := randSessionID(t)
+
+	initial := &oauth2.Token{AccessToken: "access-1", RefreshToken: "refresh-1", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	if err := s.Put(ctx, sessionID, initial); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, nonce, err := s.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if err := s.Revoke(ctx, sessionID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	rotated := &oauth2.Token{AccessToken: "access-2", RefreshToken: "refresh-2", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	if _, err := s.putIfCurrent(ctx, sessionID, rotated, nonce); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("putIfCurrent after revoke: got %v, want ErrRevoked", err)
+	}
+}